@@ -0,0 +1,317 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Transport is implemented by anything a Client can send JSON-RPC requests
+// over. RoundTrip sends a single marshaled request or batch and returns the
+// raw response body; it is all a request/response transport such as HTTP
+// needs to provide.
+type Transport interface {
+	RoundTrip(ctx context.Context, req []byte) ([]byte, error)
+}
+
+// StreamTransport is an optional extension of Transport implemented by
+// long-lived, full-duplex transports - WebSocket, IPC - that can hand back
+// the underlying Conn so a caller can make server-initiated calls and
+// subscriptions work.
+type StreamTransport interface {
+	Transport
+	Stream() *Conn
+}
+
+// Client calls methods on a remote JSON-RPC server reachable through
+// transport, mirroring Server on the calling side.
+type Client struct {
+	transport Transport
+	nextID    int64
+}
+
+// NewClient creates a Client that sends requests over transport.
+func NewClient(transport Transport) *Client {
+	return &Client{transport: transport}
+}
+
+// Call invokes method on the server with params, the way buildArguments
+// expects them (positional, in order), and unmarshals the result into out,
+// which may be nil if the caller does not care about the result.
+func (c *Client) Call(ctx context.Context, method string, out any, params ...any) error {
+	id := json.Number(strconv.FormatInt(atomic.AddInt64(&c.nextID, 1), 10))
+
+	reqJSON, err := json.Marshal(&request{Version: "2.0", Method: method, Params: paramsList(params), ID: id})
+	if err != nil {
+		return err
+	}
+
+	resJSON, err := c.transport.RoundTrip(ctx, reqJSON)
+	if err != nil {
+		return err
+	}
+
+	res, err := decodeResponse(resJSON)
+	if err != nil {
+		return err
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if out == nil {
+		return nil
+	}
+
+	resultJSON, err := json.Marshal(res.Result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(resultJSON, out)
+}
+
+// Notify invokes method on the server with params without waiting for a
+// result.
+func (c *Client) Notify(ctx context.Context, method string, params ...any) error {
+	reqJSON, err := json.Marshal(&request{Version: "2.0", Method: method, Params: paramsList(params)})
+	if err != nil {
+		return err
+	}
+	_, err = c.transport.RoundTrip(ctx, reqJSON)
+	return err
+}
+
+// BatchElem is a single call within a Client.BatchCall. Result must be a
+// pointer, or nil if the caller does not care about that call's result;
+// after BatchCall returns, Error holds that call's *Error, if any.
+type BatchElem struct {
+	Method string
+	Params []any
+	Result any
+	Error  error
+}
+
+// BatchCall sends every element of calls to the server as a single JSON-RPC
+// batch and fills in each element's Result and Error in place.
+func (c *Client) BatchCall(ctx context.Context, calls ...BatchElem) error {
+	batch := make([]*request, len(calls))
+	byID := make(map[any]int, len(calls))
+	for i := range calls {
+		id := json.Number(strconv.FormatInt(atomic.AddInt64(&c.nextID, 1), 10))
+		batch[i] = &request{Version: "2.0", Method: calls[i].Method, Params: paramsList(calls[i].Params), ID: id}
+		byID[id] = i
+	}
+
+	batchJSON, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	resJSON, err := c.transport.RoundTrip(ctx, batchJSON)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(resJSON))
+	dec.UseNumber()
+	var responses []response
+	if err := dec.Decode(&responses); err != nil {
+		return err
+	}
+
+	for i := range responses {
+		res := &responses[i]
+		idx, found := byID[res.ID]
+		if !found {
+			continue
+		}
+
+		if res.Error != nil {
+			calls[idx].Error = res.Error
+			continue
+		}
+		if calls[idx].Result == nil {
+			continue
+		}
+		resultJSON, err := json.Marshal(res.Result)
+		if err != nil {
+			calls[idx].Error = err
+			continue
+		}
+		calls[idx].Error = json.Unmarshal(resultJSON, calls[idx].Result)
+	}
+
+	return nil
+}
+
+func decodeResponse(resJSON []byte) (*response, error) {
+	dec := json.NewDecoder(bytes.NewReader(resJSON))
+	dec.UseNumber()
+	res := new(response)
+	if err := dec.Decode(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func paramsList(params []any) any {
+	if len(params) == 0 {
+		return nil
+	}
+	return params
+}
+
+// HTTPTransport is a Transport that POSTs requests to a Juno RPC endpoint,
+// reusing a tuned *http.Client so repeated calls benefit from connection
+// keep-alive instead of paying a new TCP/TLS handshake each time, the same
+// fix go-ethereum made when it added HTTP connection caching to its client.
+type HTTPTransport struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPTransport creates an HTTPTransport that sends requests to url.
+func NewHTTPTransport(url string) *HTTPTransport {
+	return &HTTPTransport{
+		url: url,
+		client: &http.Client{
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: 20,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+	}
+}
+
+func (t *HTTPTransport) RoundTrip(ctx context.Context, reqJSON []byte) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(reqJSON))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpRes, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpRes.Body.Close()
+
+	return io.ReadAll(httpRes.Body)
+}
+
+// IPCTransport is a Transport backed by a persistent net.Conn (a Unix domain
+// socket or a Windows named pipe), correlating requests with their replies
+// the same way Conn does for server-initiated calls, so that a single
+// connection can be shared by concurrent RoundTrip callers.
+type IPCTransport struct {
+	conn *Conn
+}
+
+// DialIPC connects to addr over network (for example "unix") and returns an
+// IPCTransport backed by the connection. The returned transport's Stream
+// method exposes the underlying Conn for subscriptions and server-initiated
+// calls. ctx governs the connection's entire lifetime, not just the dial:
+// canceling it closes the connection and unblocks any in-flight RoundTrip,
+// the same as calling Stream().Close() directly.
+func DialIPC(ctx context.Context, network, addr string) (*IPCTransport, error) {
+	netConn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := NewConn(nil, netConn)
+	go func() { _ = conn.Serve(ctx) }()
+
+	return &IPCTransport{conn: conn}, nil
+}
+
+func (t *IPCTransport) RoundTrip(ctx context.Context, reqJSON []byte) ([]byte, error) {
+	ids, err := requestIDs(reqJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		// A notification, or a batch made up entirely of notifications: the
+		// peer never replies, so there's nothing to correlate or wait for.
+		_, err := t.conn.Write(reqJSON)
+		return nil, err
+	}
+
+	if len(ids) == 1 {
+		res, err := t.conn.roundTrip(ctx, ids[0], reqJSON)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(res)
+	}
+
+	responses, err := t.conn.batchRoundTrip(ctx, ids, reqJSON)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(responses)
+}
+
+// requestIDs returns the ID of every element of reqJSON that carries one -
+// reqJSON may be a single request or a JSON-RPC batch - in encounter order.
+// Elements without an ID are notifications and are omitted.
+func requestIDs(reqJSON []byte) ([]any, error) {
+	trimmed := bytes.TrimLeft(reqJSON, " \t\r\n")
+	if len(trimmed) == 0 {
+		return nil, errors.New("empty request")
+	}
+
+	decodeID := func(raw []byte) (any, error) {
+		var elem struct {
+			ID any `json:"id"`
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&elem); err != nil {
+			return nil, err
+		}
+		return elem.ID, nil
+	}
+
+	if trimmed[0] != '[' {
+		id, err := decodeID(reqJSON)
+		if err != nil {
+			return nil, err
+		}
+		if id == nil {
+			return nil, nil
+		}
+		return []any{id}, nil
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(reqJSON, &batch); err != nil {
+		return nil, err
+	}
+
+	ids := make([]any, 0, len(batch))
+	for _, elem := range batch {
+		id, err := decodeID(elem)
+		if err != nil {
+			return nil, err
+		}
+		if id != nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Stream returns the Conn backing this transport, so callers can make
+// server-initiated calls or consume pushed subscription notifications with
+// Conn.OnNotify.
+func (t *IPCTransport) Stream() *Conn {
+	return t.conn
+}
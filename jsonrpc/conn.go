@@ -0,0 +1,298 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Conn wraps a full-duplex stream (for example a WebSocket or an IPC socket)
+// and treats both peers symmetrically, modeled on the connection abstraction
+// in golang/tools' internal/jsonrpc2. Inbound messages are demultiplexed into
+// either handler invocations, dispatched through the owning Server, or
+// replies matched to outbound calls the local side is waiting on. This lets
+// subsystems that hold a Conn - such as sync or p2p - push events to a
+// connected client over the same socket the client used to call in.
+type Conn struct {
+	rw     io.ReadWriteCloser
+	server *Server
+	notify atomic.Pointer[func(raw json.RawMessage)]
+
+	writeMu sync.Mutex
+
+	nextID    int64
+	pending   map[any]chan *response
+	pendingMu sync.Mutex
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewConn creates a Conn that dispatches inbound requests arriving on rw
+// through server, and allows the local side to make outbound calls and
+// notifications over the same stream. server may be nil for a client-only
+// Conn that never receives requests of its own, such as one backing an
+// IPCTransport; inbound messages that aren't replies are then routed to the
+// callback set with OnNotify, if any, instead of being dispatched.
+func NewConn(server *Server, rw io.ReadWriteCloser) *Conn {
+	return &Conn{
+		rw:      rw,
+		server:  server,
+		pending: make(map[any]chan *response),
+		closed:  make(chan struct{}),
+	}
+}
+
+// OnNotify registers fn to be called with inbound messages that are not
+// replies to an outstanding Call, on a Conn with no Server to dispatch them
+// to - for example server-pushed subscription notifications reaching a
+// client. It may be called before or while Serve runs; such messages that
+// arrive before a callback is set are dropped.
+func (c *Conn) OnNotify(fn func(raw json.RawMessage)) {
+	c.notify.Store(&fn)
+}
+
+// Write implements io.Writer so that a Conn can be stashed under ConnKey{} in
+// a request context, letting handlers push notifications back to the peer
+// that is being served.
+func (c *Conn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.rw.Write(p)
+}
+
+// Close closes the underlying stream and unblocks any outstanding Call.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.rw.Close()
+}
+
+// Serve reads framed messages off the underlying stream until it is closed or
+// ctx is done, dispatching inbound requests to the Server and routing inbound
+// responses to the Call that is waiting for them. It returns the error that
+// ended the loop, which is io.EOF on a clean close.
+//
+// The underlying read is blocking, so ctx being done unblocks it by closing
+// the connection, the same way Close does; Serve returns the resulting error
+// rather than ctx.Err() to keep a single, consistent "why did the loop end"
+// signal.
+func (c *Conn) Serve(ctx context.Context) error {
+	ctx = context.WithValue(ctx, ConnKey{}, io.Writer(c))
+
+	stopOnDone := context.AfterFunc(ctx, func() { c.Close() })
+	defer stopOnDone()
+
+	dec := json.NewDecoder(c.rw)
+	dec.UseNumber()
+
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			// The peer is gone; unwind everything we were doing on its
+			// behalf, including any live subscriptions, instead of letting
+			// handlers run to completion or leaking forwarding goroutines.
+			if c.server != nil {
+				c.server.cancelScope(c)
+				c.server.closeConnSubscriptions(c)
+			}
+			return err
+		}
+
+		c.handleMessage(ctx, raw)
+	}
+}
+
+// handleMessage routes a single inbound message to the Server or to the
+// pending Call waiting for it. A batch - a JSON array - is split and each of
+// its elements routed independently, since a batch reply is just several
+// responses sent as one message.
+func (c *Conn) handleMessage(ctx context.Context, raw json.RawMessage) {
+	if isBatchMessage(raw) {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(raw, &batch); err != nil {
+			return
+		}
+		for _, elem := range batch {
+			c.handleMessage(ctx, elem)
+		}
+		return
+	}
+
+	var peek struct {
+		Method string `json:"method"`
+		ID     any    `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &peek); err != nil {
+		// Not a well-formed message; nothing sensible to correlate it
+		// with, so drop it rather than tear down the whole connection.
+		return
+	}
+
+	if peek.Method == "" && peek.ID != nil {
+		c.dispatchReply(raw)
+		return
+	}
+
+	if c.server == nil {
+		// No Server to bound concurrency for; dispatchRequest just forwards
+		// to the notify callback, so a bare goroutine is enough to avoid
+		// blocking the read loop on a slow callback.
+		go c.dispatchRequest(ctx, raw)
+		return
+	}
+
+	// Route through the Server's pool, the same way handleBatchRequest and
+	// WebsocketServer.serveConn do, so a single Conn can't force unbounded
+	// concurrent handler invocations regardless of poolMaxGoroutines.
+	c.server.pool.Go(func() { c.dispatchRequest(ctx, raw) })
+}
+
+func isBatchMessage(raw json.RawMessage) bool {
+	trimmed := bytes.TrimLeft(raw, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+func (c *Conn) dispatchRequest(ctx context.Context, raw json.RawMessage) {
+	if c.server == nil {
+		if notify := c.notify.Load(); notify != nil {
+			(*notify)(raw)
+		}
+		return
+	}
+
+	res, err := c.server.HandleReader(ctx, bytes.NewReader(raw))
+	if err != nil || res == nil {
+		return
+	}
+	if _, err := c.Write(res); err != nil {
+		c.server.log.Errorw("failed to write RPC response", "err", err)
+	}
+}
+
+func (c *Conn) dispatchReply(raw json.RawMessage) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	res := new(response)
+	if err := dec.Decode(res); err != nil {
+		return
+	}
+
+	c.pendingMu.Lock()
+	ch, ok := c.pending[res.ID]
+	delete(c.pending, res.ID)
+	c.pendingMu.Unlock()
+
+	if ok {
+		ch <- res
+	}
+}
+
+// Call sends method with params to the peer and blocks until a reply arrives,
+// unmarshaling its result into result, which may be nil if the caller does
+// not care about the result.
+func (c *Conn) Call(ctx context.Context, method string, params, result any) error {
+	id := json.Number(strconv.FormatInt(atomic.AddInt64(&c.nextID, 1), 10))
+
+	reqJSON, err := json.Marshal(&request{Version: "2.0", Method: method, Params: params, ID: id})
+	if err != nil {
+		return err
+	}
+
+	res, err := c.roundTrip(ctx, id, reqJSON)
+	if err != nil {
+		return err
+	}
+	if res.Error != nil {
+		return fmt.Errorf("%w", res.Error)
+	}
+	if result == nil {
+		return nil
+	}
+
+	resultJSON, err := json.Marshal(res.Result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(resultJSON, result)
+}
+
+// roundTrip writes reqJSON, a request already carrying id, and blocks until
+// the matching reply arrives, the connection closes, or ctx is done.
+func (c *Conn) roundTrip(ctx context.Context, id any, reqJSON []byte) (*response, error) {
+	ch := make(chan *response, 1)
+	c.pendingMu.Lock()
+	c.pending[id] = ch
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, id)
+		c.pendingMu.Unlock()
+	}()
+
+	if _, err := c.Write(reqJSON); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.closed:
+		return nil, errors.New("connection closed")
+	case res := <-ch:
+		return res, nil
+	}
+}
+
+// batchRoundTrip writes reqJSON, a batch already carrying every id in ids,
+// and waits for each of their replies to arrive, in the same order as ids.
+func (c *Conn) batchRoundTrip(ctx context.Context, ids []any, reqJSON []byte) ([]*response, error) {
+	chans := make([]chan *response, len(ids))
+	c.pendingMu.Lock()
+	for i, id := range ids {
+		ch := make(chan *response, 1)
+		chans[i] = ch
+		c.pending[id] = ch
+	}
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		for _, id := range ids {
+			delete(c.pending, id)
+		}
+		c.pendingMu.Unlock()
+	}()
+
+	if _, err := c.Write(reqJSON); err != nil {
+		return nil, err
+	}
+
+	responses := make([]*response, len(ids))
+	for i := range ids {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.closed:
+			return nil, errors.New("connection closed")
+		case res := <-chans[i]:
+			responses[i] = res
+		}
+	}
+	return responses, nil
+}
+
+// Notify sends method with params to the peer without waiting for a reply.
+func (c *Conn) Notify(ctx context.Context, method string, params any) error {
+	reqJSON, err := json.Marshal(&request{Version: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	_, err = c.Write(reqJSON)
+	return err
+}
@@ -0,0 +1,169 @@
+package jsonrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/NethermindEth/juno/utils"
+)
+
+// pipeConns returns a Conn wrapping server (nil for a client-only side) and
+// its peer, connected by an in-memory net.Pipe, mirroring one end of a real
+// Conn/Server pairing such as WebsocketServer or DialIPC without the network.
+func pipeConns(t *testing.T, server *Server) (serverSide, clientSide *Conn) {
+	t.Helper()
+	a, b := net.Pipe()
+	serverSide = NewConn(server, a)
+	clientSide = NewConn(nil, b)
+	return serverSide, clientSide
+}
+
+func echoServer(t *testing.T) *Server {
+	t.Helper()
+	s := NewServer(2, utils.NewNopZapLogger())
+	if err := s.RegisterMethods(Method{
+		Name:   "echo",
+		Params: []Parameter{{Name: "msg"}},
+		Handler: func(msg string) (string, *Error) {
+			return msg, nil
+		},
+	}); err != nil {
+		t.Fatalf("register echo: %v", err)
+	}
+	return s
+}
+
+func TestConnCallAndNotify(t *testing.T) {
+	serverSide, clientSide := pipeConns(t, echoServer(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = serverSide.Serve(ctx) }()
+	go func() { _ = clientSide.Serve(ctx) }()
+
+	var result string
+	if err := clientSide.Call(ctx, "echo", []any{"hello"}, &result); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if result != "hello" {
+		t.Fatalf("got %q, want %q", result, "hello")
+	}
+
+	// A notification carries no id, so there is no reply to wait for; it
+	// should simply not hang or error.
+	if err := clientSide.Notify(ctx, "echo", []any{"ignored"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+}
+
+func TestConnBatchCallCorrelatesRepliesInOrder(t *testing.T) {
+	serverSide, clientSide := pipeConns(t, echoServer(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = serverSide.Serve(ctx) }()
+	go func() { _ = clientSide.Serve(ctx) }()
+
+	client := NewClient(&pipeTransport{conn: clientSide})
+
+	calls := []BatchElem{
+		{Method: "echo", Params: []any{"one"}, Result: new(string)},
+		{Method: "echo", Params: []any{"two"}, Result: new(string)},
+		{Method: "echo", Params: []any{"three"}, Result: new(string)},
+	}
+	if err := client.BatchCall(ctx, calls...); err != nil {
+		t.Fatalf("BatchCall: %v", err)
+	}
+
+	want := []string{"one", "two", "three"}
+	for i, call := range calls {
+		if call.Error != nil {
+			t.Fatalf("call %d: %v", i, call.Error)
+		}
+		if got := *call.Result.(*string); got != want[i] {
+			t.Fatalf("call %d: got %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+// pipeTransport adapts a *Conn, which speaks per-id Call/roundTrip, to the
+// batch-capable Transport interface client.go's IPCTransport implements, so
+// BatchCall can be exercised without a real net.Conn.
+type pipeTransport struct {
+	conn *Conn
+}
+
+func (p *pipeTransport) RoundTrip(ctx context.Context, reqJSON []byte) ([]byte, error) {
+	return (&IPCTransport{conn: p.conn}).RoundTrip(ctx, reqJSON)
+}
+
+// TestCancelScopeDoesNotCrossConnections exercises the scoping review fix (a)
+// addressed: a disconnecting connection must only cancel the handlers it is
+// itself waiting on, not every in-flight request sharing the Server, even
+// when two connections' client-chosen request IDs collide.
+func TestCancelScopeDoesNotCrossConnections(t *testing.T) {
+	server := NewServer(4, utils.NewNopZapLogger())
+	started := make(chan struct{}, 2)
+	finished := make(chan struct{}, 2)
+	if err := server.RegisterMethods(Method{
+		Name: "block",
+		Handler: func(ctx context.Context) (any, *Error) {
+			started <- struct{}{}
+			<-ctx.Done()
+			finished <- struct{}{}
+			return nil, Err(InternalError, "canceled")
+		},
+	}); err != nil {
+		t.Fatalf("register block: %v", err)
+	}
+
+	serverSide1, clientSide1 := pipeConns(t, server)
+	serverSide2, clientSide2 := pipeConns(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = serverSide1.Serve(ctx) }()
+	go func() { _ = clientSide1.Serve(ctx) }()
+	go func() { _ = serverSide2.Serve(ctx) }()
+	go func() { _ = clientSide2.Serve(ctx) }()
+
+	// Both connections happen to number their first request "1", since each
+	// Conn's nextID counter starts independently.
+	go func() { _ = clientSide1.Call(ctx, "block", nil, nil) }()
+	go func() { _ = clientSide2.Call(ctx, "block", nil, nil) }()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("block handler did not start")
+		}
+	}
+
+	// Simulate connection 2 disconnecting, the same way a real read error
+	// would: this must only unblock the handler running on its behalf.
+	if err := serverSide2.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("connection 2's handler was not canceled when it disconnected")
+	}
+
+	select {
+	case <-finished:
+		t.Fatal("connection 1's handler was canceled by connection 2's disconnect")
+	case <-time.After(100 * time.Millisecond):
+		// Still blocked, as expected; unblock it for cleanup below.
+	}
+
+	cancel()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("connection 1's handler was not canceled on shutdown")
+	}
+}
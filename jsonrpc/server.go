@@ -15,6 +15,8 @@ import (
 
 	"github.com/NethermindEth/juno/utils"
 	"github.com/sourcegraph/conc/pool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 const (
@@ -33,10 +35,50 @@ var (
 )
 
 type request struct {
-	Version string `json:"jsonrpc"`
-	Method  string `json:"method"`
-	Params  any    `json:"params,omitempty"`
-	ID      any    `json:"id,omitempty"`
+	Version string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Params  any               `json:"params,omitempty"`
+	ID      any               `json:"id,omitempty"`
+	Meta    map[string]string `json:"meta,omitempty"`
+}
+
+// Reserved Meta keys that, when present, are parsed as W3C trace context so
+// a span from an upstream caller survives the RPC boundary, as Lotus's
+// jsonrpc handler does with its own meta field.
+const (
+	traceparentKey = "traceparent"
+	tracestateKey  = "tracestate"
+)
+
+type metaKey struct{}
+
+// MetaFromContext returns the meta map a request carried in its optional
+// top-level meta field, if any.
+func MetaFromContext(ctx context.Context) (map[string]string, bool) {
+	meta, ok := ctx.Value(metaKey{}).(map[string]string)
+	return meta, ok
+}
+
+// startSpanFromMeta extracts a W3C trace context from req's meta, if its
+// reserved traceparent key is set, and starts a child span so a trace from
+// an upstream caller links correctly across the RPC boundary. It returns the
+// (possibly unchanged) context and a function the caller must invoke to end
+// the span.
+func (s *Server) startSpanFromMeta(ctx context.Context, method string) (context.Context, func()) {
+	meta, _ := MetaFromContext(ctx)
+	traceparent, ok := meta[traceparentKey]
+	if !ok {
+		return ctx, func() {}
+	}
+
+	carrier := propagation.MapCarrier{traceparentKey: traceparent}
+	if tracestate, ok := meta[tracestateKey]; ok {
+		carrier[tracestateKey] = tracestate
+	}
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+
+	ctx, span := otel.Tracer("jsonrpc").Start(ctx, method)
+	return ctx, func() { span.End() }
 }
 
 type response struct {
@@ -52,6 +94,10 @@ type Error struct {
 	Data    any    `json:"data,omitempty"`
 }
 
+func (e *Error) Error() string {
+	return e.Message
+}
+
 func Err(code int, data any) *Error {
 	switch code {
 	case InvalidJSON:
@@ -108,12 +154,24 @@ type Method struct {
 	needsContext bool
 }
 
+// DefaultCancelMethod is the built-in method name that cancels an in-flight
+// request by ID, matching the pattern of LSP's $/cancelRequest.
+const DefaultCancelMethod = "rpc.cancel"
+
 type Server struct {
 	methods   map[string]Method
 	validator Validator
 	pool      *pool.Pool
 	log       utils.SimpleLogger
 	listener  EventListener
+
+	cancelMethod string
+	handling     map[handlingKey]context.CancelFunc
+	handlingMu   sync.Mutex
+
+	subs      map[io.Writer]map[string]*Subscription
+	subsMu    sync.Mutex
+	nextSubID int64
 }
 
 type Validator interface {
@@ -123,11 +181,16 @@ type Validator interface {
 // NewServer instantiates a JSONRPC server
 func NewServer(poolMaxGoroutines int, log utils.SimpleLogger) *Server {
 	s := &Server{
-		log:      log,
-		methods:  make(map[string]Method),
-		pool:     pool.New().WithMaxGoroutines(poolMaxGoroutines),
-		listener: &SelectiveListener{},
+		log:          log,
+		methods:      make(map[string]Method),
+		pool:         pool.New().WithMaxGoroutines(poolMaxGoroutines),
+		listener:     &SelectiveListener{},
+		cancelMethod: DefaultCancelMethod,
+		handling:     make(map[handlingKey]context.CancelFunc),
+		subs:         make(map[io.Writer]map[string]*Subscription),
 	}
+	s.mustRegisterCancelMethod()
+	s.mustRegisterUnsubscribeMethod()
 
 	return s
 }
@@ -144,6 +207,87 @@ func (s *Server) WithListener(listener EventListener) *Server {
 	return s
 }
 
+// WithCancelMethod overrides the method name that cancels an in-flight
+// request by ID. It defaults to DefaultCancelMethod.
+func (s *Server) WithCancelMethod(name string) *Server {
+	delete(s.methods, s.cancelMethod)
+	s.cancelMethod = name
+	s.mustRegisterCancelMethod()
+	return s
+}
+
+// handlingKey identifies one in-flight request: its ID together with the
+// scope it was received on. Scoping by more than just the client-chosen ID
+// matters because that ID is only unique per connection - two WebSocket
+// clients sharing a Server commonly both start numbering their requests at
+// 1 - so without the scope, one connection's rpc.cancel, or even just a
+// request completing, could reach into a different connection's handling
+// entry.
+type handlingKey struct {
+	scope any
+	id    any
+}
+
+type requestScopeKey struct{}
+
+// scopeFromContext returns the identity that handling entries created while
+// serving ctx should be scoped under: the writer of the connection the
+// request arrived on, if any, or else a token HandleReader assigned to that
+// particular call (shared across a batch, but not across separate calls).
+func scopeFromContext(ctx context.Context) any {
+	if writer, ok := ConnFromContext(ctx); ok {
+		return writer
+	}
+	return ctx.Value(requestScopeKey{})
+}
+
+func (s *Server) mustRegisterCancelMethod() {
+	if err := s.registerMethod(Method{
+		Name:   s.cancelMethod,
+		Params: []Parameter{{Name: "id"}},
+		Handler: func(ctx context.Context, rawID json.RawMessage) (any, *Error) {
+			// Decode the ID the same way request IDs are decoded (numbers as
+			// json.Number), so it compares equal to the handling key.
+			var id any
+			dec := json.NewDecoder(bytes.NewReader(rawID))
+			dec.UseNumber()
+			if err := dec.Decode(&id); err != nil {
+				return nil, Err(InvalidParams, err.Error())
+			}
+			key := handlingKey{scope: scopeFromContext(ctx), id: id}
+
+			s.handlingMu.Lock()
+			cancel, found := s.handling[key]
+			s.handlingMu.Unlock()
+			if !found {
+				return nil, Err(InvalidParams, "unknown request id")
+			}
+			cancel()
+			return nil, nil
+		},
+	}); err != nil {
+		// The handler above is constructed by us and always matches the
+		// shape registerMethod expects; a failure here is a programmer error.
+		panic(err)
+	}
+}
+
+// cancelScope cancels every request currently being handled within scope. It
+// is meant to be called with the writer identity of a connection whose
+// underlying stream has just closed, so handlers working on behalf of that
+// connection unwind instead of running to completion - without touching
+// requests in flight on any other connection sharing this Server.
+func (s *Server) cancelScope(scope any) {
+	s.handlingMu.Lock()
+	defer s.handlingMu.Unlock()
+	for key, cancel := range s.handling {
+		if key.scope == scope {
+			cancel()
+			delete(s.handling, key)
+		}
+	}
+}
+
 // RegisterMethods verifies and creates an endpoint that the server recognises.
 //
 // - name is the method name
@@ -195,6 +339,9 @@ func (s *Server) Handle(ctx context.Context, data []byte) ([]byte, error) {
 	return s.HandleReader(ctx, bytes.NewReader(data))
 }
 
+// ConnKey is the context key under which a connection's writer is stored, so
+// that handlers invoked through HandleReader can push notifications back to
+// the peer they are serving (see Conn and WebsocketServer).
 type ConnKey struct{}
 
 func ConnFromContext(ctx context.Context) (io.Writer, bool) {
@@ -209,7 +356,20 @@ func ConnFromContext(ctx context.Context) (io.Writer, bool) {
 // HandleReader processes a request to the server
 // It returns the response in a byte array, only returns an
 // error if it can not create the response byte array
+//
+// It is safe to call repeatedly with a ctx carrying the same ConnKey writer,
+// which is how Conn and WebsocketServer dispatch the many inbound requests
+// that arrive over a single long-lived connection.
 func (s *Server) HandleReader(ctx context.Context, reader io.Reader) ([]byte, error) {
+	if _, hasConn := ConnFromContext(ctx); !hasConn {
+		// Requests arriving outside a stateful Conn/WebSocket connection
+		// (for example over plain HTTP) have no connection identity to scope
+		// handling entries by; give this call its own, so that two unrelated
+		// HandleReader calls that happen to reuse the same request ID can't
+		// clobber each other's cancellation entry.
+		ctx = context.WithValue(ctx, requestScopeKey{}, new(int))
+	}
+
 	bufferedReader := bufio.NewReaderSize(reader, bufferSize)
 	requestIsBatch := isBatch(bufferedReader)
 	res := &response{
@@ -343,6 +503,14 @@ func (s *Server) handleRequest(ctx context.Context, req *request) (*response, er
 		return nil, err
 	}
 
+	if req.Meta != nil {
+		ctx = context.WithValue(ctx, metaKey{}, req.Meta)
+
+		var endSpan func()
+		ctx, endSpan = s.startSpanFromMeta(ctx, req.Method)
+		defer endSpan()
+	}
+
 	res := &response{
 		Version: "2.0",
 		ID:      req.ID,
@@ -354,6 +522,39 @@ func (s *Server) handleRequest(ctx context.Context, req *request) (*response, er
 		return res, nil
 	}
 
+	isSubscribeMethod := reflect.TypeOf(calledMethod.Handler).Out(0) == subscriptionType
+
+	// A subscription only makes sense for a request that will get an ID back
+	// to key the subscription on; calling the handler as a notification would
+	// construct a Subscription that never gets registered or torn down, and
+	// whose first Send would then block forever. Reject it before invoking
+	// the handler instead of silently dropping the result.
+	if req.ID == nil && isSubscribeMethod {
+		res.Error = Err(InvalidRequest, "subscriptions require a request with an id")
+		return res, nil
+	}
+
+	// A subscription outlives the request/response round trip that creates
+	// it - it keeps streaming events long after handleRequest returns - so
+	// it must not be derived from a context that this function cancels on
+	// return. Only non-subscribe methods get the cancel-on-return context
+	// that powers rpc.cancel; a subscription's lifetime is instead governed
+	// by rpc.unsubscribe and connection teardown (see registerSubscription).
+	if req.ID != nil && !isSubscribeMethod {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		key := handlingKey{scope: scopeFromContext(ctx), id: req.ID}
+		s.handlingMu.Lock()
+		s.handling[key] = cancel
+		s.handlingMu.Unlock()
+		defer func() {
+			s.handlingMu.Lock()
+			delete(s.handling, key)
+			s.handlingMu.Unlock()
+			cancel()
+		}()
+	}
+
 	handlerTimer := time.Now()
 	s.listener.OnNewRequest(req.Method)
 	args, err := s.buildArguments(ctx, req.Params, calledMethod)
@@ -378,6 +579,17 @@ func (s *Server) handleRequest(ctx context.Context, req *request) (*response, er
 		s.listener.OnRequestFailed(req.Method, err)
 		return res, nil
 	}
+
+	if sub, ok := tuple[0].Interface().(*Subscription); ok {
+		writer, connected := ConnFromContext(ctx)
+		if !connected {
+			res.Error = Err(InternalError, "subscriptions require a stateful connection")
+			return res, nil
+		}
+		res.Result = s.registerSubscription(writer, notifyMethodFor(req.Method), sub)
+		return res, nil
+	}
+
 	res.Result = tuple[0].Interface()
 	return res, nil
 }
@@ -0,0 +1,61 @@
+package jsonrpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NethermindEth/juno/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMetaFromContext(t *testing.T) {
+	if _, ok := MetaFromContext(context.Background()); ok {
+		t.Fatal("expected no meta on a bare context")
+	}
+
+	meta := map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+	ctx := context.WithValue(context.Background(), metaKey{}, meta)
+
+	got, ok := MetaFromContext(ctx)
+	if !ok {
+		t.Fatal("expected meta to be found")
+	}
+	if got["traceparent"] != meta["traceparent"] {
+		t.Fatalf("got %v, want %v", got, meta)
+	}
+}
+
+func TestStartSpanFromMetaWithoutTraceparent(t *testing.T) {
+	s := NewServer(1, utils.NewNopZapLogger())
+
+	ctx := context.WithValue(context.Background(), metaKey{}, map[string]string{})
+	spanCtx, endSpan := s.startSpanFromMeta(ctx, "some_method")
+	defer endSpan()
+
+	if spanCtx != ctx {
+		t.Fatal("expected the context to pass through unchanged when meta has no traceparent")
+	}
+}
+
+func TestStartSpanFromMetaWithTraceparent(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	s := NewServer(1, utils.NewNopZapLogger())
+	meta := map[string]string{
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+	ctx := context.WithValue(context.Background(), metaKey{}, meta)
+
+	spanCtx, endSpan := s.startSpanFromMeta(ctx, "some_method")
+	defer endSpan()
+
+	got := trace.SpanContextFromContext(spanCtx)
+	if !got.IsValid() {
+		t.Fatal("expected a valid span context linked from the traceparent")
+	}
+	if want := "4bf92f3577b34da6a3ce929d0e0e4736"; got.TraceID().String() != want {
+		t.Fatalf("got trace id %s, want %s", got.TraceID().String(), want)
+	}
+}
@@ -0,0 +1,170 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Subscription is returned by a handler that wants to stream events to its
+// caller instead of returning a single result, the pattern used by
+// go-ethereum's v2 RPC and Lotus's jsonrpc library. A handler constructs one
+// with NewSubscription, pushes events with Send, and returns it as its first
+// return value; the server takes care of allocating a subscription ID,
+// framing each event as a notification, and tearing the subscription down
+// when the client unsubscribes or the connection closes.
+type Subscription struct {
+	id     string
+	ctx    context.Context
+	cancel context.CancelFunc
+	feed   chan any
+}
+
+// NewSubscription creates a Subscription bound to ctx. Its context is
+// canceled, and Send starts failing, once the client unsubscribes or the
+// underlying connection closes.
+func NewSubscription(ctx context.Context) *Subscription {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Subscription{
+		ctx:    ctx,
+		cancel: cancel,
+		feed:   make(chan any),
+	}
+}
+
+// Context returns the subscription's context.
+func (s *Subscription) Context() context.Context {
+	return s.ctx
+}
+
+// Send pushes event to the subscriber, blocking until it is delivered or the
+// subscription is torn down.
+func (s *Subscription) Send(event any) error {
+	select {
+	case s.feed <- event:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// subscriptionType is *Subscription's reflect.Type, used to recognize a
+// subscribe method's handler by its first return value before it is ever
+// called.
+var subscriptionType = reflect.TypeOf(&Subscription{})
+
+type subscriptionNotification struct {
+	Subscription string `json:"subscription"`
+	Result       any    `json:"result"`
+}
+
+// registerSubscription allocates an ID for sub, registers it against writer
+// so that rpc.unsubscribe and connection teardown can find it, and starts
+// forwarding sub's events to writer as notifications named after notifyMethod
+// (conventionally the subscribe method with its "_subscribe" suffix swapped
+// for "_subscription").
+func (s *Server) registerSubscription(writer io.Writer, notifyMethod string, sub *Subscription) string {
+	sub.id = strconv.FormatInt(atomic.AddInt64(&s.nextSubID, 1), 10)
+
+	s.subsMu.Lock()
+	conn, found := s.subs[writer]
+	if !found {
+		conn = make(map[string]*Subscription)
+		s.subs[writer] = conn
+	}
+	conn[sub.id] = sub
+	s.subsMu.Unlock()
+
+	go func() {
+		defer s.removeSubscription(writer, sub.id)
+		for {
+			select {
+			case event := <-sub.feed:
+				notification := &request{
+					Version: "2.0",
+					Method:  notifyMethod,
+					Params: &subscriptionNotification{
+						Subscription: sub.id,
+						Result:       event,
+					},
+				}
+				notificationJSON, err := json.Marshal(notification)
+				if err != nil {
+					s.log.Errorw("failed to marshal subscription notification", "err", err)
+					continue
+				}
+				if _, err := writer.Write(notificationJSON); err != nil {
+					s.log.Errorw("failed to write subscription notification", "err", err)
+					sub.cancel()
+					return
+				}
+			case <-sub.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return sub.id
+}
+
+func (s *Server) removeSubscription(writer io.Writer, id string) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	conn, found := s.subs[writer]
+	if !found {
+		return
+	}
+	if sub, found := conn[id]; found {
+		sub.cancel()
+		delete(conn, id)
+	}
+	if len(conn) == 0 {
+		delete(s.subs, writer)
+	}
+}
+
+// closeConnSubscriptions cancels every subscription registered against
+// writer. It is called when the connection the subscriptions were streamed
+// over is torn down, draining the goroutines that forward their events.
+func (s *Server) closeConnSubscriptions(writer io.Writer) {
+	s.subsMu.Lock()
+	conn := s.subs[writer]
+	delete(s.subs, writer)
+	s.subsMu.Unlock()
+
+	for _, sub := range conn {
+		sub.cancel()
+	}
+}
+
+func (s *Server) mustRegisterUnsubscribeMethod() {
+	if err := s.registerMethod(Method{
+		Name:   "rpc.unsubscribe",
+		Params: []Parameter{{Name: "id"}},
+		Handler: func(ctx context.Context, id string) (bool, *Error) {
+			writer, ok := ConnFromContext(ctx)
+			if !ok {
+				return false, Err(InvalidRequest, "subscriptions require a stateful connection")
+			}
+			s.removeSubscription(writer, id)
+			return true, nil
+		},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// notifyMethodFor derives the notification method name used when forwarding
+// a subscription's events, following go-ethereum's convention of swapping a
+// "_subscribe" suffix for "_subscription".
+func notifyMethodFor(subscribeMethod string) string {
+	if trimmed, found := strings.CutSuffix(subscribeMethod, "_subscribe"); found {
+		return trimmed + "_subscription"
+	}
+	return subscribeMethod + "_subscription"
+}
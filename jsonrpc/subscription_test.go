@@ -0,0 +1,105 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NethermindEth/juno/utils"
+)
+
+func feedServer(t *testing.T, sent chan<- *Subscription) *Server {
+	t.Helper()
+	s := NewServer(2, utils.NewNopZapLogger())
+	if err := s.RegisterMethods(Method{
+		Name: "feed_subscribe",
+		Handler: func(ctx context.Context) (*Subscription, *Error) {
+			sub := NewSubscription(ctx)
+			sent <- sub
+			return sub, nil
+		},
+	}); err != nil {
+		t.Fatalf("register feed_subscribe: %v", err)
+	}
+	return s
+}
+
+func TestSubscriptionDeliversEventsAndTearsDownOnClose(t *testing.T) {
+	subs := make(chan *Subscription, 1)
+	server := feedServer(t, subs)
+	serverSide, clientSide := pipeConns(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = serverSide.Serve(ctx) }()
+
+	notifications := make(chan json.RawMessage, 1)
+	clientSide.OnNotify(func(raw json.RawMessage) { notifications <- raw })
+	go func() { _ = clientSide.Serve(ctx) }()
+
+	var subID string
+	if err := clientSide.Call(ctx, "feed_subscribe", nil, &subID); err != nil {
+		t.Fatalf("feed_subscribe: %v", err)
+	}
+	if subID == "" {
+		t.Fatal("expected a non-empty subscription id")
+	}
+
+	sub := <-subs
+	if err := sub.Send("event1"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	select {
+	case raw := <-notifications:
+		if got := string(raw); !strings.Contains(got, subID) || !strings.Contains(got, "event1") {
+			t.Fatalf("notification %s missing subscription id %s or event", got, subID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("notification was not delivered")
+	}
+
+	// Closing the connection the subscription was registered against must
+	// tear it down so its forwarding goroutine doesn't leak and a later Send
+	// doesn't block forever.
+	if err := serverSide.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	select {
+	case <-sub.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not canceled when its connection closed")
+	}
+}
+
+func TestSubscribeAsNotificationIsRejectedWithoutInvokingHandler(t *testing.T) {
+	subs := make(chan *Subscription, 1)
+	server := feedServer(t, subs)
+	serverSide, clientSide := pipeConns(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = serverSide.Serve(ctx) }()
+	go func() { _ = clientSide.Serve(ctx) }()
+
+	if err := clientSide.Notify(ctx, "feed_subscribe", nil); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	// A notification never gets a reply to synchronize on, so give the
+	// (would-be) handler a chance to run before asserting it didn't.
+	select {
+	case <-subs:
+		t.Fatal("feed_subscribe handler ran for a request with no id")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	server.subsMu.Lock()
+	defer server.subsMu.Unlock()
+	if len(server.subs) != 0 {
+		t.Fatalf("expected no subscriptions to be registered, got %d", len(server.subs))
+	}
+}
@@ -0,0 +1,111 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/NethermindEth/juno/utils"
+	"github.com/gorilla/websocket"
+)
+
+const writeWait = 10 * time.Second
+
+var wsUpgrader = websocket.Upgrader{
+	// Juno's RPC is consumed by browser wallets and tooling served from
+	// origins we don't control; the method itself is what's access-controlled.
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// WebsocketServer adapts a Server to the WebSocket transport: it upgrades
+// incoming HTTP connections with gorilla/websocket and runs a full-duplex
+// read/write loop per connection, the transport that makes the subscription
+// and bidirectional-call features usable in practice - similar to what
+// Lotus's jsonrpc package added on top of its handler.
+type WebsocketServer struct {
+	server *Server
+	log    utils.SimpleLogger
+}
+
+// NewWebsocketServer wraps server for use as an http.Handler over WebSocket.
+// Callers that need graceful shutdown should arrange for the *http.Server's
+// BaseContext to be canceled so in-flight connections unwind (see
+// http.Server.Shutdown): WebsocketServer cancels every handler running on a
+// connection and sends a close frame as soon as that connection's request
+// context is done.
+func NewWebsocketServer(server *Server, log utils.SimpleLogger) *WebsocketServer {
+	return &WebsocketServer{server: server, log: log}
+}
+
+func (ws *WebsocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		ws.log.Errorw("failed to upgrade websocket connection", "err", err)
+		return
+	}
+	ws.serveConn(r.Context(), conn)
+}
+
+// wsWriter serializes writes onto a websocket.Conn, which is not safe for
+// concurrent use, so that both RPC responses and pushed subscription
+// notifications can share it.
+type wsWriter struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (w *wsWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.conn.WriteMessage(websocket.TextMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (ws *WebsocketServer) serveConn(ctx context.Context, conn *websocket.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	writer := &wsWriter{conn: conn}
+	ctx = context.WithValue(ctx, ConnKey{}, io.Writer(writer))
+
+	go func() {
+		<-ctx.Done()
+		ws.server.cancelScope(writer)
+		ws.server.closeConnSubscriptions(writer)
+		closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		_ = conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(writeWait))
+		conn.Close()
+	}()
+
+	for {
+		msgType, reader, err := conn.NextReader()
+		if err != nil {
+			return
+		}
+		if msgType != websocket.TextMessage && msgType != websocket.BinaryMessage {
+			closeMsg := websocket.FormatCloseMessage(websocket.CloseUnsupportedData, "only text and binary frames are supported")
+			_ = conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(writeWait))
+			return
+		}
+
+		raw, err := io.ReadAll(reader)
+		if err != nil {
+			return
+		}
+
+		ws.server.pool.Go(func() {
+			res, handleErr := ws.server.HandleReader(ctx, bytes.NewReader(raw))
+			if handleErr != nil || res == nil {
+				return
+			}
+			if _, writeErr := writer.Write(res); writeErr != nil {
+				ws.log.Errorw("failed to write websocket response", "err", writeErr)
+			}
+		})
+	}
+}
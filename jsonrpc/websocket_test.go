@@ -0,0 +1,150 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NethermindEth/juno/utils"
+	"github.com/gorilla/websocket"
+)
+
+func dialWebsocket(t *testing.T, ts *httptest.Server) *websocket.Conn {
+	t.Helper()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestWebsocketServerRoundTrip(t *testing.T) {
+	ts := httptest.NewServer(NewWebsocketServer(echoServer(t), utils.NewNopZapLogger()))
+	defer ts.Close()
+
+	conn := dialWebsocket(t, ts)
+	if err := conn.WriteJSON(&request{Version: "2.0", Method: "echo", Params: []any{"hello"}, ID: json.Number("1")}); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+
+	var res response
+	if err := conn.ReadJSON(&res); err != nil {
+		t.Fatalf("ReadJSON: %v", err)
+	}
+	if res.Error != nil {
+		t.Fatalf("unexpected error: %v", res.Error)
+	}
+	if res.Result != "hello" {
+		t.Fatalf("got %v, want %q", res.Result, "hello")
+	}
+}
+
+// TestWebsocketServerCancelsHandlersOnShutdown exercises the documented
+// contract of NewWebsocketServer: canceling the *http.Server's BaseContext
+// must cancel every handler running on every connection, and send each one a
+// close frame, rather than leaving them to run to completion.
+func TestWebsocketServerCancelsHandlersOnShutdown(t *testing.T) {
+	server := NewServer(2, utils.NewNopZapLogger())
+	started := make(chan struct{}, 1)
+	finished := make(chan struct{}, 1)
+	if err := server.RegisterMethods(Method{
+		Name: "block",
+		Handler: func(ctx context.Context) (any, *Error) {
+			started <- struct{}{}
+			<-ctx.Done()
+			finished <- struct{}{}
+			return nil, Err(InternalError, "canceled")
+		},
+	}); err != nil {
+		t.Fatalf("register block: %v", err)
+	}
+
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+
+	ts := httptest.NewUnstartedServer(NewWebsocketServer(server, utils.NewNopZapLogger()))
+	ts.Config.BaseContext = func(_ net.Listener) context.Context { return baseCtx }
+	ts.Start()
+	defer ts.Close()
+
+	conn := dialWebsocket(t, ts)
+	if err := conn.WriteJSON(&request{Version: "2.0", Method: "block", ID: json.Number("1")}); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("block handler did not start")
+	}
+
+	cancelBase()
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("block handler was not canceled when BaseContext was canceled")
+	}
+
+	// The server also sends a close frame and closes the socket; the client
+	// should observe the connection ending rather than hanging indefinitely.
+	// The canceled handler's own error response may still arrive first (its
+	// write and the close frame come from two independent goroutines racing
+	// on the same ctx.Done()), so drain messages until the close lands.
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	closed := false
+	for i := 0; i < 10; i++ {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			closed = true
+			break
+		}
+	}
+	if !closed {
+		t.Fatal("expected the connection to be closed after shutdown")
+	}
+}
+
+func TestWebsocketServerSubscriptionTeardownOnDisconnect(t *testing.T) {
+	subs := make(chan *Subscription, 1)
+	server := feedServer(t, subs)
+
+	ts := httptest.NewServer(NewWebsocketServer(server, utils.NewNopZapLogger()))
+	defer ts.Close()
+
+	conn := dialWebsocket(t, ts)
+	if err := conn.WriteJSON(&request{Version: "2.0", Method: "feed_subscribe", ID: json.Number("1")}); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+
+	var res response
+	if err := conn.ReadJSON(&res); err != nil {
+		t.Fatalf("ReadJSON: %v", err)
+	}
+	if res.Error != nil {
+		t.Fatalf("unexpected error: %v", res.Error)
+	}
+
+	sub := <-subs
+	if err := sub.Send("event1"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var notification request
+	if err := conn.ReadJSON(&notification); err != nil {
+		t.Fatalf("ReadJSON notification: %v", err)
+	}
+
+	conn.Close()
+
+	select {
+	case <-sub.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not canceled when its connection closed")
+	}
+}